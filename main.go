@@ -1,16 +1,15 @@
 package main
 
 import (
-	"encoding/json"
+	"context"
 	"flag"
-	"fmt"
 	"io"
 	"log"
 	"os"
 
 	"github.com/jonas-p/go-shp"
-	"github.com/paulmach/orb"
-	"github.com/paulmach/orb/geojson"
+
+	"github.com/wsw0108/shp2geojson/pkg/shp2geojson"
 )
 
 var (
@@ -19,6 +18,13 @@ var (
 	encoding string
 	ndjson   bool
 	pretty   bool
+	tSRS     string
+	sSRS     string
+	limitTo  string
+	limitBuf float64
+	format   string
+	dropZ    bool
+	workers  int
 )
 
 func init() {
@@ -27,6 +33,13 @@ func init() {
 	flag.StringVar(&encoding, "e", "", "encoding of dbf")
 	flag.BoolVar(&ndjson, "ndjson", false, "output as ndjson")
 	flag.BoolVar(&pretty, "pretty", false, "pretty, no effect when output as ndjson")
+	flag.StringVar(&tSRS, "t-srs", "EPSG:4326", "target SRS to reproject coordinates to")
+	flag.StringVar(&sSRS, "s-srs", "", "source SRS, overrides the .prj sidecar file when set")
+	flag.StringVar(&limitTo, "limitto", "", "clip output to the union of the Polygon/MultiPolygon features in this GeoJSON file; straddling features are intersected against the polygons' actual shape")
+	flag.Float64Var(&limitBuf, "limitto-buffer", 0, "buffer to apply around -limitto's bounding-box pre-check, in output CRS units; does not buffer the clip shape itself")
+	flag.StringVar(&format, "format", "geojson", "output format: geojson, wkb, gpkg or pgcopy")
+	flag.BoolVar(&dropZ, "drop-z", false, "discard Z values from PointZ/PolyLineZ/PolygonZ/MultiPointZ/MultiPatch shapes, emitting strict 2D coordinates")
+	flag.IntVar(&workers, "j", 1, "number of worker goroutines converting shapes to features in parallel")
 	flag.Parse()
 }
 
@@ -43,36 +56,34 @@ func main() {
 	if err != nil {
 		log.Fatalln(err)
 	}
-	fields := reader.Fields()
-	collection := geojson.NewFeatureCollection()
-	for reader.Next() {
-		n, shape := reader.Shape()
-		var attrs []shp.Attribute
-		for k := range fields {
-			attr := reader.ReadAttribute(n, k)
-			if attr != nil {
-				attrs = append(attrs, attr)
-			}
-		}
-		collection.Features = append(collection.Features, ShapeToFeature(shape, attrs))
+	defer reader.Close()
+
+	proj, err := shp2geojson.Reprojector(input, sSRS, tSRS)
+	if err != nil {
+		log.Fatalln(err)
 	}
+	lim, err := shp2geojson.NewLimiter(limitTo, limitBuf)
+	if err != nil {
+		log.Fatalln(err)
+	}
+
 	out, err := getOutput(output)
 	if err != nil {
 		log.Fatalln(err)
 	}
 	defer out.Close()
-	encoder := json.NewEncoder(out)
-	if !ndjson {
-		if pretty {
-			encoder.SetIndent("", "  ")
-		}
-		if err := encoder.Encode(collection); err != nil {
-			log.Fatalln(err)
-		}
-	} else {
-		for _, feature := range collection.Features {
-			encoder.Encode(feature)
-		}
+
+	opts := shp2geojson.Options{
+		Format:  format,
+		NDJSON:  ndjson,
+		Pretty:  pretty,
+		Project: proj,
+		Limiter: lim,
+		DropZ:   dropZ,
+		Workers: workers,
+	}
+	if err := shp2geojson.Convert(context.Background(), reader, out, opts); err != nil {
+		log.Fatalln(err)
 	}
 }
 
@@ -84,125 +95,3 @@ func getOutput(output string) (out io.WriteCloser, err error) {
 	out, err = os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
 	return
 }
-
-func ShapeToFeature(shape shp.Shape, attrs []shp.Attribute) *geojson.Feature {
-	var g orb.Geometry
-	switch s := shape.(type) {
-	case *shp.Point:
-		g = convertPoint(s)
-	case *shp.PolyLine:
-		if s.NumParts == 1 {
-			g = convertLineString(s)
-		} else if s.NumParts > 1 {
-			g = convertMultiLineString(s)
-		}
-	case *shp.PolyLineZ:
-		if s.NumParts == 1 {
-			g = convertLineStringZ(s)
-		} else if s.NumParts > 1 {
-			g = convertMultiLineStringZ(s)
-		}
-	case *shp.Polygon:
-		g = convertMultiPolygon(s)
-	case *shp.MultiPoint:
-		g = convertMultiPoint(s)
-	default:
-		panic(fmt.Sprintf("unsupported geometry type %v", s))
-	}
-	f := geojson.NewFeature(g)
-	for _, attr := range attrs {
-		f.Properties[attr.Name()] = attr.Value()
-	}
-	return f
-}
-
-func convertPoint(s *shp.Point) orb.Point {
-	return orb.Point{s.X, s.Y}
-}
-
-func convertLineString(s *shp.PolyLine) orb.LineString {
-	g := orb.LineString{}
-	for _, p := range s.Points {
-		g = append(g, convertPoint(&p))
-	}
-	return g
-}
-
-func convertLineStringZ(s *shp.PolyLineZ) orb.LineString {
-	g := orb.LineString{}
-	for _, p := range s.Points {
-		g = append(g, convertPoint(&p))
-	}
-	return g
-}
-
-func convertMultiPoint(s *shp.MultiPoint) orb.MultiPoint {
-	g := orb.MultiPoint{}
-	for _, p := range s.Points {
-		g = append(g, convertPoint(&p))
-	}
-	return g
-}
-
-func convertMultiLineString(s *shp.PolyLine) orb.MultiLineString {
-	g := orb.MultiLineString{}
-	for i, start := range s.Parts {
-		var end int32
-		if int32(i) < s.NumParts-1 {
-			end = s.Parts[i+1]
-		} else {
-			end = s.NumPoints
-		}
-		l := orb.LineString{}
-		for _, p := range s.Points[start:end] {
-			l = append(l, convertPoint(&p))
-		}
-		g = append(g, l)
-	}
-	return g
-}
-
-func convertMultiLineStringZ(s *shp.PolyLineZ) orb.MultiLineString {
-	g := orb.MultiLineString{}
-	for i, start := range s.Parts {
-		var end int32
-		if int32(i) < s.NumParts-1 {
-			end = s.Parts[i+1]
-		} else {
-			end = s.NumPoints
-		}
-		l := orb.LineString{}
-		for _, p := range s.Points[start:end] {
-			l = append(l, convertPoint(&p))
-		}
-		g = append(g, l)
-	}
-	return g
-}
-
-func convertMultiPolygon(s *shp.Polygon) orb.MultiPolygon {
-	g := orb.MultiPolygon{}
-	var poly orb.Polygon
-	for i, start := range s.Parts {
-		var end int32
-		if int32(i) < s.NumParts-1 {
-			end = s.Parts[i+1]
-		} else {
-			end = s.NumPoints
-		}
-		r := orb.Ring{}
-		for _, p := range s.Points[start:end] {
-			r = append(r, convertPoint(&p))
-		}
-		if i == 0 {
-			poly = append(poly, r)
-		} else if r.Orientation() == orb.CW {
-			g = append(g, poly)
-			poly = orb.Polygon{}
-			poly = append(poly, r)
-		} else {
-			poly = append(poly, r)
-		}
-	}
-	return g
-}
@@ -0,0 +1,66 @@
+package shp2geojson
+
+import (
+	"context"
+	"io"
+
+	"github.com/jonas-p/go-shp"
+)
+
+// Options configures a Convert call.
+type Options struct {
+	// Format selects the output FeatureWriter: "geojson" (the default),
+	// "wkb", "gpkg" or "pgcopy".
+	Format string
+	// NDJSON writes one Feature per line instead of a single
+	// FeatureCollection. Only applies to the geojson format.
+	NDJSON bool
+	// Pretty indents the FeatureCollection. It has no effect when NDJSON is
+	// set or the format isn't geojson.
+	Pretty bool
+	// Project, when non-nil, is applied to every coordinate before it is
+	// written out.
+	Project ProjectFunc
+	// Limiter, when non-nil, drops or clips features outside of its bound.
+	Limiter *Limiter
+	// DropZ discards Z values from Z-flavored shapes (PointZ, PolyLineZ,
+	// PolygonZ, MultiPointZ, MultiPatch), producing strict two-dimensional
+	// RFC 7946 coordinates instead of GeoJSON 2008-style [x,y,z] ones.
+	DropZ bool
+	// Workers is the number of goroutines running ShapeToFeature (including
+	// reprojection and polygon ring assembly) concurrently. Values <= 1
+	// convert sequentially.
+	Workers int
+}
+
+// Convert reads shapes and attributes from r and writes them to w using
+// the FeatureWriter selected by opts.Format. In the default streaming
+// GeoJSON mode, features are written out one at a time rather than
+// buffered in memory, so conversion runs in constant memory regardless of
+// shapefile size. When opts.Workers is greater than 1, shapes are still
+// read from r sequentially but converted by a pool of worker goroutines
+// and reassembled into their original order before being written; see
+// pipeline.go. Convert stops and returns the first error encountered,
+// which may be ctx.Err() if ctx is canceled.
+func Convert(ctx context.Context, r shp.SequentialReader, w io.Writer, opts Options) error {
+	fields := attributeFields(r.Fields())
+
+	fw, err := NewFeatureWriter(opts.Format, w, fields, opts)
+	if err != nil {
+		return err
+	}
+
+	if err := convertPipeline(ctx, r, fw, opts); err != nil {
+		return err
+	}
+
+	return fw.Close()
+}
+
+func attributeFields(fields []shp.Field) []AttributeField {
+	result := make([]AttributeField, len(fields))
+	for i, field := range fields {
+		result[i] = AttributeField{Name: field.String(), Type: AttributeType(field.Fieldtype)}
+	}
+	return result
+}
@@ -0,0 +1,85 @@
+package shp2geojson
+
+import (
+	"encoding/json"
+
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+)
+
+// Feature is shp2geojson's feature representation. Geometry is either an
+// orb.Geometry (the common 2D case) or a *geometry3 when the source shape
+// carried Z values that Options.DropZ didn't discard; orb's geometry types
+// only support two dimensions, so geometry3 is marshaled directly instead
+// of going through orb/geojson.
+type Feature struct {
+	Geometry   interface{}
+	Properties map[string]interface{}
+}
+
+func newFeature(g interface{}) *Feature {
+	return &Feature{Geometry: g, Properties: make(map[string]interface{})}
+}
+
+// MarshalJSON converts the feature into a GeoJSON Feature object.
+func (f *Feature) MarshalJSON() ([]byte, error) {
+	var geom json.RawMessage
+	var err error
+	switch g := f.Geometry.(type) {
+	case nil:
+		geom = []byte("null")
+	case orb.Geometry:
+		geom, err = json.Marshal(geojson.NewGeometry(g))
+	case *geometry3:
+		geom, err = g.MarshalJSON()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var props interface{} = f.Properties
+	if len(f.Properties) == 0 {
+		props = nil
+	}
+
+	return json.Marshal(struct {
+		Type       string          `json:"type"`
+		Geometry   json.RawMessage `json:"geometry"`
+		Properties interface{}     `json:"properties"`
+	}{Type: "Feature", Geometry: geom, Properties: props})
+}
+
+// geometry3 is a 3D GeoJSON geometry. coords nests [3]float64 positions to
+// whatever depth typ requires, mirroring the orb type it stands in for
+// (e.g. a "LineString" nests one level, a "MultiPolygon" nests three).
+type geometry3 struct {
+	typ    string
+	coords interface{}
+	flat   orb.Geometry
+}
+
+// To2D returns the geometry's X/Y projection, for writers (wkb, gpkg,
+// pgcopy, -limitto) that only operate on two dimensions.
+func (g *geometry3) To2D() orb.Geometry {
+	return g.flat
+}
+
+func (g *geometry3) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type        string      `json:"type"`
+		Coordinates interface{} `json:"coordinates"`
+	}{Type: g.typ, Coordinates: g.coords})
+}
+
+// to2D extracts the orb.Geometry backing a feature geometry, whether it's
+// already 2D or a geometry3 carrying Z.
+func to2D(g interface{}) orb.Geometry {
+	switch g := g.(type) {
+	case orb.Geometry:
+		return g
+	case *geometry3:
+		return g.To2D()
+	default:
+		return nil
+	}
+}
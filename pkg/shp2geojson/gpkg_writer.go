@@ -0,0 +1,193 @@
+package shp2geojson
+
+import (
+	"database/sql"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/paulmach/orb/encoding/wkb"
+
+	_ "modernc.org/sqlite"
+)
+
+// gpkgWriter writes features into a GeoPackage (a SQLite database with a
+// handful of required metadata tables). SQLite needs a real file to work
+// against, so gpkgWriter builds it in a temp file and copies the finished
+// database into w on Close.
+type gpkgWriter struct {
+	w       io.Writer
+	tmpPath string
+	db      *sql.DB
+	tx      *sql.Tx
+	insert  *sql.Stmt
+	fields  []AttributeField
+}
+
+func newGpkgWriter(w io.Writer, fields []AttributeField) (*gpkgWriter, error) {
+	tmp, err := os.CreateTemp("", "shp2geojson-*.gpkg")
+	if err != nil {
+		return nil, err
+	}
+	tmpPath := tmp.Name()
+	tmp.Close()
+
+	db, err := sql.Open("sqlite", tmpPath)
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, err
+	}
+
+	gw := &gpkgWriter{w: w, tmpPath: tmpPath, db: db, fields: fields}
+	if err := gw.init(); err != nil {
+		db.Close()
+		os.Remove(tmpPath)
+		return nil, err
+	}
+	return gw, nil
+}
+
+func (gw *gpkgWriter) init() error {
+	schema := []string{
+		`CREATE TABLE gpkg_spatial_ref_sys (
+			srs_name TEXT NOT NULL,
+			srs_id INTEGER PRIMARY KEY,
+			organization TEXT NOT NULL,
+			organization_coordsys_id INTEGER NOT NULL,
+			definition TEXT NOT NULL,
+			description TEXT
+		)`,
+		`CREATE TABLE gpkg_contents (
+			table_name TEXT PRIMARY KEY,
+			data_type TEXT NOT NULL,
+			identifier TEXT UNIQUE,
+			description TEXT DEFAULT '',
+			last_change DATETIME NOT NULL,
+			min_x DOUBLE, min_y DOUBLE, max_x DOUBLE, max_y DOUBLE,
+			srs_id INTEGER NOT NULL
+		)`,
+		`CREATE TABLE gpkg_geometry_columns (
+			table_name TEXT NOT NULL,
+			column_name TEXT NOT NULL,
+			geometry_type_name TEXT NOT NULL,
+			srs_id INTEGER NOT NULL,
+			z TINYINT NOT NULL,
+			m TINYINT NOT NULL,
+			PRIMARY KEY (table_name, column_name)
+		)`,
+		`INSERT INTO gpkg_spatial_ref_sys (srs_name, srs_id, organization, organization_coordsys_id, definition, description)
+			VALUES ('WGS 84 geodetic', 4326, 'EPSG', 4326, 'GEOGCS["WGS 84"]', 'longitude/latitude coordinates')`,
+		fmt.Sprintf(`INSERT INTO gpkg_contents (table_name, data_type, identifier, last_change, srs_id)
+			VALUES ('features', 'features', 'features', %q, 4326)`, time.Now().UTC().Format("2006-01-02T15:04:05.000Z")),
+		`INSERT INTO gpkg_geometry_columns (table_name, column_name, geometry_type_name, srs_id, z, m)
+			VALUES ('features', 'geom', 'GEOMETRY', 4326, 0, 0)`,
+	}
+
+	var cols strings.Builder
+	cols.WriteString("fid INTEGER PRIMARY KEY AUTOINCREMENT, geom BLOB")
+	for _, field := range gw.fields {
+		fmt.Fprintf(&cols, ", %s %s", quoteIdent(field.Name), sqlColumnType(field.Type))
+	}
+	schema = append(schema, fmt.Sprintf("CREATE TABLE features (%s)", cols.String()))
+
+	for _, stmt := range schema {
+		if _, err := gw.db.Exec(stmt); err != nil {
+			return fmt.Errorf("gpkg: %w", err)
+		}
+	}
+
+	tx, err := gw.db.Begin()
+	if err != nil {
+		return err
+	}
+	gw.tx = tx
+
+	placeholders := make([]string, 0, len(gw.fields)+1)
+	placeholders = append(placeholders, "?")
+	columnNames := []string{"geom"}
+	for _, field := range gw.fields {
+		placeholders = append(placeholders, "?")
+		columnNames = append(columnNames, quoteIdent(field.Name))
+	}
+	insertSQL := fmt.Sprintf("INSERT INTO features (%s) VALUES (%s)",
+		strings.Join(columnNames, ", "), strings.Join(placeholders, ", "))
+	stmt, err := tx.Prepare(insertSQL)
+	if err != nil {
+		return err
+	}
+	gw.insert = stmt
+	return nil
+}
+
+func (gw *gpkgWriter) WriteFeature(f *Feature) error {
+	geomWKB, err := wkb.Marshal(to2D(f.Geometry))
+	if err != nil {
+		return err
+	}
+	blob := gpkgGeometryBlob(4326, geomWKB)
+
+	args := make([]interface{}, 0, len(gw.fields)+1)
+	args = append(args, blob)
+	for _, field := range gw.fields {
+		args = append(args, f.Properties[field.Name])
+	}
+	_, err = gw.insert.Exec(args...)
+	return err
+}
+
+func (gw *gpkgWriter) Close() error {
+	defer os.Remove(gw.tmpPath)
+
+	if err := gw.insert.Close(); err != nil {
+		return err
+	}
+	if err := gw.tx.Commit(); err != nil {
+		return err
+	}
+	if err := gw.db.Close(); err != nil {
+		return err
+	}
+
+	f, err := os.Open(gw.tmpPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(gw.w, f)
+	return err
+}
+
+// gpkgGeometryBlob wraps WKB geometry bytes in the GeoPackageBinary header
+// required by the GeoPackage spec (magic, version, flags, SRS id).
+func gpkgGeometryBlob(srid int32, geomWKB []byte) []byte {
+	header := make([]byte, 8)
+	header[0] = 'G'
+	header[1] = 'P'
+	header[2] = 0    // version
+	header[3] = 0x01 // little-endian, no envelope, not empty
+	binary.LittleEndian.PutUint32(header[4:], uint32(srid))
+	return append(header, geomWKB...)
+}
+
+// quoteIdent quotes name as a SQL identifier, doubling any embedded double
+// quotes per the SQL standard (and SQLite's identifier syntax) -- unlike
+// %q, which applies Go string-literal escaping and produces invalid SQL
+// for a name containing a double quote.
+func quoteIdent(name string) string {
+	return `"` + strings.ReplaceAll(name, `"`, `""`) + `"`
+}
+
+func sqlColumnType(t AttributeType) string {
+	switch t {
+	case AttributeNumber, AttributeFloat:
+		return "REAL"
+	case AttributeDate:
+		return "DATE"
+	default:
+		return "TEXT"
+	}
+}
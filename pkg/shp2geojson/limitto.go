@@ -0,0 +1,391 @@
+package shp2geojson
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/akavel/polyclip-go"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/geojson"
+	"github.com/paulmach/orb/planar"
+)
+
+// Limiter drops or clips emitted features against the union of a
+// Polygon/MultiPolygon FeatureCollection's rings, so a country/city-shaped
+// subset of a large shapefile can be extracted in one pass without a
+// separate ogr2ogr step. Polygon/MultiPolygon features straddling the
+// boundary are intersected against the clip shape with polyclip-go, a
+// pure-Go implementation of the Martinez-Rueda-Feito polygon boolean-op
+// algorithm; their properties are preserved, and an input polygon that is
+// split into several disjoint pieces by the clip shape comes out as a
+// single MultiPolygon feature rather than several features, since
+// shp2geojson converts one shape to exactly one feature. Point/MultiPoint
+// features are kept or dropped by an exact point-in-polygon test, and
+// LineString/MultiLineString features are cut at the clip boundary.
+//
+// bound is kept alongside clip purely as a fast bounding-box pre-check
+// before the more expensive exact tests below.
+type Limiter struct {
+	bound orb.Bound
+	clip  polyclip.Polygon
+}
+
+// NewLimiter loads the Polygon/MultiPolygon features in path and returns a
+// Limiter clipping to their union. buffer pads the bounding-box pre-check
+// (in the same units as the emitted coordinates, i.e. degrees when the
+// output SRS is the default EPSG:4326); it does not buffer the clip
+// shape's actual boundary, so it only ever admits more candidates to the
+// exact clip below, never fewer. A nil Limiter is returned when path is
+// empty, so callers can call its methods unconditionally.
+func NewLimiter(path string, buffer float64) (*Limiter, error) {
+	if path == "" {
+		return nil, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("-limitto: %w", err)
+	}
+	fc, err := geojson.UnmarshalFeatureCollection(data)
+	if err != nil {
+		return nil, fmt.Errorf("-limitto: %w", err)
+	}
+
+	var bound orb.Bound
+	var clipPoly polyclip.Polygon
+	have := false
+	for _, f := range fc.Features {
+		var polys orb.MultiPolygon
+		switch g := f.Geometry.(type) {
+		case orb.Polygon:
+			polys = orb.MultiPolygon{g}
+		case orb.MultiPolygon:
+			polys = g
+		default:
+			continue
+		}
+		for _, poly := range polys {
+			for _, r := range poly {
+				clipPoly = append(clipPoly, ringToContour(r))
+			}
+		}
+		if !have {
+			bound = f.Geometry.Bound()
+			have = true
+		} else {
+			bound = bound.Union(f.Geometry.Bound())
+		}
+	}
+	if !have {
+		return nil, fmt.Errorf("-limitto: %s has no Polygon/MultiPolygon features", path)
+	}
+
+	if buffer != 0 {
+		bound = bound.Pad(buffer)
+	}
+	return &Limiter{bound: bound, clip: clipPoly}, nil
+}
+
+// Clip clips g against the Limiter's polygon shape, returning nil when g
+// falls entirely outside of it. A nil Limiter passes g through unchanged.
+func (l *Limiter) Clip(g orb.Geometry) orb.Geometry {
+	if l == nil {
+		return g
+	}
+	if g == nil || !l.bound.Intersects(g.Bound()) {
+		return nil
+	}
+
+	switch geom := g.(type) {
+	case orb.Point:
+		if l.containsPoint(geom) {
+			return geom
+		}
+		return nil
+	case orb.MultiPoint:
+		var kept orb.MultiPoint
+		for _, p := range geom {
+			if l.containsPoint(p) {
+				kept = append(kept, p)
+			}
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		return kept
+	case orb.LineString:
+		lines := l.clipLineString(geom)
+		if len(lines) == 0 {
+			return nil
+		}
+		if len(lines) == 1 {
+			return lines[0]
+		}
+		return orb.MultiLineString(lines)
+	case orb.MultiLineString:
+		var kept orb.MultiLineString
+		for _, ls := range geom {
+			kept = append(kept, l.clipLineString(ls)...)
+		}
+		if len(kept) == 0 {
+			return nil
+		}
+		return kept
+	case orb.Polygon:
+		return l.clipPolygons(geom)
+	case orb.MultiPolygon:
+		var rings []orb.Ring
+		for _, poly := range geom {
+			rings = append(rings, poly...)
+		}
+		return l.clipPolygons(rings)
+	default:
+		return g
+	}
+}
+
+// ClipOrKeep clips a Feature's Geometry value, which may be an orb.Geometry
+// or a *geometry3 carrying Z, against the Limiter's polygon shape (see the
+// Limiter doc comment). A geometry3 is tested by its flattened X/Y
+// projection and either kept whole (its Z values intact) or dropped,
+// rather than being reshaped, since reshaping would require interpolating
+// Z along newly introduced clip edges. A nil Limiter passes g through
+// unchanged.
+func (l *Limiter) ClipOrKeep(g interface{}) interface{} {
+	if l == nil {
+		return g
+	}
+	switch geom := g.(type) {
+	case orb.Geometry:
+		clipped := l.Clip(geom)
+		if clipped == nil {
+			return nil
+		}
+		return clipped
+	case *geometry3:
+		if l.Clip(geom.flat) == nil {
+			return nil
+		}
+		return geom
+	default:
+		return g
+	}
+}
+
+// containsPoint reports whether p lies inside the clip shape, by an
+// even-odd count of ray crossings across all of its contours. This is
+// correct for holes and multiple disjoint clip polygons alike, as long as
+// the contours don't overlap.
+func (l *Limiter) containsPoint(p orb.Point) bool {
+	pt := polyclip.Point{X: p[0], Y: p[1]}
+	inside := false
+	for _, c := range l.clip {
+		if c.Contains(pt) {
+			inside = !inside
+		}
+	}
+	return inside
+}
+
+// clipPolygons intersects the polygon made up of rings (an outer ring plus
+// any holes, in whatever order and winding they arrive in) against the
+// clip shape, returning the pieces that survive as a MultiPolygon, or nil
+// when nothing survives.
+func (l *Limiter) clipPolygons(rings []orb.Ring) orb.MultiPolygon {
+	subject := make(polyclip.Polygon, 0, len(rings))
+	for _, r := range rings {
+		subject = append(subject, ringToContour(r))
+	}
+	result := subject.Construct(polyclip.INTERSECTION, l.clip)
+
+	var out []orb.Ring
+	for _, c := range result {
+		if r := contourToRing(c); len(r) >= 4 {
+			out = append(out, r)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return nestRings(out)
+}
+
+// clipLineString splits ls at the clip boundary, returning the sub-lines
+// that lie inside it, in order along ls. Endpoints exactly on the
+// boundary are resolved by testing the midpoint of each candidate
+// sub-line rather than the endpoints themselves, which is robust to a cut
+// landing exactly on a vertex.
+func (l *Limiter) clipLineString(ls orb.LineString) []orb.LineString {
+	if len(ls) < 2 {
+		return nil
+	}
+
+	var out []orb.LineString
+	var current orb.LineString
+	flush := func() {
+		if len(current) >= 2 {
+			out = append(out, current)
+		}
+		current = nil
+	}
+
+	for i := 0; i < len(ls)-1; i++ {
+		a, b := ls[i], ls[i+1]
+		ts := []float64{0, 1}
+		for _, c := range l.clip {
+			n := len(c)
+			for j := 0; j < n; j++ {
+				p1, p2 := c[j], c[(j+1)%n]
+				if t, ok := segmentIntersection(a, b, orb.Point{p1.X, p1.Y}, orb.Point{p2.X, p2.Y}); ok {
+					ts = append(ts, t)
+				}
+			}
+		}
+		sort.Float64s(ts)
+
+		for k := 0; k < len(ts)-1; k++ {
+			t0, t1 := ts[k], ts[k+1]
+			if t1-t0 < 1e-12 {
+				continue
+			}
+			segStart := lerp(a, b, t0)
+			segEnd := lerp(a, b, t1)
+			if l.containsPoint(lerp(a, b, (t0+t1)/2)) {
+				if len(current) == 0 {
+					current = append(current, segStart)
+				}
+				current = append(current, segEnd)
+			} else {
+				flush()
+			}
+		}
+	}
+	flush()
+	return out
+}
+
+// ringToContour converts an orb.Ring, which repeats its first point as its
+// last, to a polyclip.Contour, which closes implicitly between its last
+// and first point.
+func ringToContour(r orb.Ring) polyclip.Contour {
+	n := len(r)
+	if n > 1 && r[0] == r[n-1] {
+		n--
+	}
+	c := make(polyclip.Contour, n)
+	for i := 0; i < n; i++ {
+		c[i] = polyclip.Point{X: r[i][0], Y: r[i][1]}
+	}
+	return c
+}
+
+// contourToRing converts a polyclip.Contour back to a closed orb.Ring.
+func contourToRing(c polyclip.Contour) orb.Ring {
+	if len(c) == 0 {
+		return nil
+	}
+	r := make(orb.Ring, len(c)+1)
+	for i, p := range c {
+		r[i] = orb.Point{p.X, p.Y}
+	}
+	r[len(c)] = r[0]
+	return r
+}
+
+// nestRings groups the rings polyclip returns for one Construct call into
+// polygons with holes, by point-containment depth rather than by the
+// winding convention assignRings uses for shapefile rings: polyclip gives
+// no guarantee about which way around its output contours wind, but
+// containment depth is unambiguous regardless. A ring contained by an
+// even number of other rings (0, 2, ...) starts a new outer polygon; one
+// contained by an odd number is a hole of its smallest enclosing ring,
+// which handles island-in-hole-in-polygon nesting as well as the common
+// single-level case.
+func nestRings(rings []orb.Ring) orb.MultiPolygon {
+	n := len(rings)
+	parent := make([]int, n)
+	area := make([]float64, n)
+	for i, r := range rings {
+		area[i] = math.Abs(planar.Area(r))
+	}
+	for i, r := range rings {
+		parent[i] = -1
+		best := math.Inf(1)
+		for j, other := range rings {
+			if i == j {
+				continue
+			}
+			if area[j] < best && planar.RingContains(other, r[0]) {
+				parent[i] = j
+				best = area[j]
+			}
+		}
+	}
+
+	depth := make([]int, n)
+	for i := range rings {
+		for p := parent[i]; p != -1; p = parent[p] {
+			depth[i]++
+		}
+	}
+
+	outers := make(map[int]*orb.Polygon)
+	var order []int
+	for i, d := range depth {
+		if d%2 == 0 {
+			poly := orb.Polygon{orientRing(rings[i], orb.CCW)}
+			outers[i] = &poly
+			order = append(order, i)
+		}
+	}
+	for i, d := range depth {
+		if d%2 != 0 {
+			if poly, ok := outers[parent[i]]; ok {
+				*poly = append(*poly, orientRing(rings[i], orb.CW))
+			}
+		}
+	}
+
+	out := make(orb.MultiPolygon, 0, len(order))
+	for _, i := range order {
+		out = append(out, *outers[i])
+	}
+	return out
+}
+
+// orientRing returns r, reversed if necessary, so its winding matches want.
+// Unlike rfc7946Winding (which flips assignRings' ESRI-convention
+// classification unconditionally), polyclip's output contours carry no
+// guaranteed winding, so this checks the ring's actual orientation first.
+func orientRing(r orb.Ring, want orb.Orientation) orb.Ring {
+	if r.Orientation() == want {
+		return r
+	}
+	out := r.Clone()
+	out.Reverse()
+	return out
+}
+
+// segmentIntersection returns the parameter t in [0,1] at which segment
+// a-b crosses segment p1-p2, if they cross within both segments' extents.
+func segmentIntersection(a, b, p1, p2 orb.Point) (float64, bool) {
+	d1x, d1y := b[0]-a[0], b[1]-a[1]
+	d2x, d2y := p2[0]-p1[0], p2[1]-p1[1]
+	denom := d1x*d2y - d1y*d2x
+	if denom == 0 {
+		return 0, false
+	}
+	ex, ey := p1[0]-a[0], p1[1]-a[1]
+	t := (ex*d2y - ey*d2x) / denom
+	u := (ex*d1y - ey*d1x) / denom
+	if t < 0 || t > 1 || u < 0 || u > 1 {
+		return 0, false
+	}
+	return t, true
+}
+
+func lerp(a, b orb.Point, t float64) orb.Point {
+	return orb.Point{a[0] + (b[0]-a[0])*t, a[1] + (b[1]-a[1])*t}
+}
@@ -0,0 +1,64 @@
+package shp2geojson
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/paulmach/orb/encoding/wkb"
+)
+
+// pgCopyWriter writes features in PostgreSQL's COPY TEXT format: one row
+// per feature, tab-separated columns, ready to be piped into
+// `psql -c "COPY table (geom, ...) FROM STDIN"`. The geom column holds hex
+// WKB, which PostGIS parses the same way ST_GeomFromWKB would.
+type pgCopyWriter struct {
+	w      io.Writer
+	fields []AttributeField
+}
+
+func newPgCopyWriter(w io.Writer, fields []AttributeField) *pgCopyWriter {
+	return &pgCopyWriter{w: w, fields: fields}
+}
+
+func (pw *pgCopyWriter) WriteFeature(f *Feature) error {
+	hexGeom, err := wkb.MarshalToHex(to2D(f.Geometry))
+	if err != nil {
+		return err
+	}
+
+	cols := make([]string, 0, len(pw.fields)+1)
+	cols = append(cols, hexGeom)
+	for _, field := range pw.fields {
+		cols = append(cols, pgCopyValue(f.Properties[field.Name]))
+	}
+
+	_, err = io.WriteString(pw.w, strings.Join(cols, "\t")+"\n")
+	return err
+}
+
+func (pw *pgCopyWriter) Close() error {
+	return nil
+}
+
+// pgCopyValue renders a property value as a COPY TEXT field, escaping the
+// characters COPY treats specially.
+func pgCopyValue(v interface{}) string {
+	if v == nil {
+		return `\N`
+	}
+
+	var s string
+	switch value := v.(type) {
+	case float64:
+		s = strconv.FormatFloat(value, 'g', -1, 64)
+	case []byte:
+		s = fmt.Sprintf("\\x%x", value)
+	default:
+		s = fmt.Sprint(value)
+	}
+
+	replacer := strings.NewReplacer(`\`, `\\`, "\t", `\t`, "\n", `\n`, "\r", `\r`)
+	return replacer.Replace(s)
+}
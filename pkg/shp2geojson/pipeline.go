@@ -0,0 +1,152 @@
+package shp2geojson
+
+import (
+	"context"
+	"sync"
+
+	"github.com/jonas-p/go-shp"
+)
+
+// shapeRecord is one shape and its attributes read off r, tagged with its
+// record number so the writer stage can put converted features back into
+// their original order.
+type shapeRecord struct {
+	n     int
+	shape shp.Shape
+	attrs []shp.Attribute
+}
+
+// featureRecord is the converted counterpart of a shapeRecord.
+type featureRecord struct {
+	n       int
+	feature *Feature
+}
+
+// convertPipeline reads shapes from r, converts them to features and
+// writes them to fw in their original order. Reading r is inherently
+// sequential (go-shp's Reader is stateful), so it always happens on a
+// single goroutine; when opts.Workers is greater than 1 the CPU-bound
+// ShapeToFeature conversion (reprojection, polygon ring assembly) runs
+// across that many worker goroutines instead, with a small reorder buffer
+// in the writer stage restoring input order before each feature is
+// written. The first error from any stage cancels the others and is
+// returned.
+func convertPipeline(ctx context.Context, r shp.SequentialReader, fw FeatureWriter, opts Options) error {
+	workers := opts.Workers
+	if workers < 1 {
+		workers = 1
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	records := make(chan shapeRecord)
+	features := make(chan featureRecord)
+
+	var readErr error
+	go func() {
+		defer close(records)
+		readErr = readShapes(ctx, r, records)
+	}()
+
+	var wg sync.WaitGroup
+	wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go func() {
+			defer wg.Done()
+			convertShapes(ctx, records, features, opts)
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(features)
+	}()
+
+	writeErr := writeFeatures(features, fw, cancel)
+
+	if writeErr != nil {
+		return writeErr
+	}
+	if readErr != nil {
+		return readErr
+	}
+	return ctx.Err()
+}
+
+// readShapes feeds one shapeRecord per shape in r into records, stopping
+// early if ctx is canceled. It returns r.Err(), the reader's own error
+// after iteration stops.
+func readShapes(ctx context.Context, r shp.SequentialReader, records chan<- shapeRecord) error {
+	for r.Next() {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		n, shape := r.Shape()
+		var attrs []shp.Attribute
+		for k := range r.Fields() {
+			if attr := r.Attribute(k); attr != nil {
+				attrs = append(attrs, attr)
+			}
+		}
+
+		select {
+		case records <- shapeRecord{n: n, shape: shape, attrs: attrs}:
+		case <-ctx.Done():
+			return nil
+		}
+	}
+	return r.Err()
+}
+
+// convertShapes runs ShapeToFeature and the limiter over every record on
+// records until it is closed or ctx is canceled.
+func convertShapes(ctx context.Context, records <-chan shapeRecord, features chan<- featureRecord, opts Options) {
+	for rec := range records {
+		feature := ShapeToFeature(rec.shape, rec.attrs, opts.Project, opts.DropZ)
+		feature.Geometry = opts.Limiter.ClipOrKeep(feature.Geometry)
+
+		select {
+		case features <- featureRecord{n: rec.n, feature: feature}:
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// writeFeatures reassembles features (which may arrive out of order when
+// there is more than one worker) into input order using a reorder buffer
+// keyed by record number, and writes each one to fw in turn. It drains
+// features to completion even after a write error, so upstream goroutines
+// cancelled by cancel are never left blocked sending to it.
+func writeFeatures(features <-chan featureRecord, fw FeatureWriter, cancel context.CancelFunc) error {
+	var writeErr error
+	pending := make(map[int]*Feature)
+	next := 0
+
+	for rec := range features {
+		if writeErr != nil {
+			continue
+		}
+
+		pending[rec.n] = rec.feature
+		for {
+			feature, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+
+			if feature.Geometry == nil {
+				continue
+			}
+			if err := fw.WriteFeature(feature); err != nil {
+				writeErr = err
+				cancel()
+				break
+			}
+		}
+	}
+	return writeErr
+}
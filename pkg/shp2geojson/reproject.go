@@ -0,0 +1,111 @@
+package shp2geojson
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/wroge/wgs84"
+)
+
+// ProjectFunc transforms a single X/Y coordinate from a shapefile's source
+// SRS into the configured target SRS. A nil ProjectFunc means no
+// reprojection is needed.
+type ProjectFunc func(x, y float64) (float64, float64)
+
+var epsgAuthorityRe = regexp.MustCompile(`AUTHORITY\[\s*"EPSG"\s*,\s*"(\d+)"\s*\]`)
+
+// Reprojector builds the ProjectFunc used to transform every coordinate
+// read from shpPath into tSRS. The source SRS is taken from sSRS when set,
+// otherwise it is detected from the shapefile's sibling .prj file. It
+// returns a nil ProjectFunc when the source and target SRS already match,
+// so callers don't pay for a no-op transform.
+func Reprojector(shpPath, sSRS, tSRS string) (ProjectFunc, error) {
+	dstEPSG, err := parseEPSGCode(tSRS)
+	if err != nil {
+		return nil, fmt.Errorf("-t-srs: %w", err)
+	}
+
+	srcEPSG, err := sourceEPSGCode(shpPath, sSRS)
+	if err != nil {
+		return nil, err
+	}
+
+	if srcEPSG == dstEPSG {
+		return nil, nil
+	}
+
+	repo := wgs84.EPSG()
+	src := repo.Code(srcEPSG)
+	if src == nil {
+		return nil, fmt.Errorf("unsupported source SRS EPSG:%d", srcEPSG)
+	}
+	dst := repo.Code(dstEPSG)
+	if dst == nil {
+		return nil, fmt.Errorf("unsupported -t-srs EPSG:%d", dstEPSG)
+	}
+
+	transform := wgs84.Transform(src, dst)
+	return func(x, y float64) (float64, float64) {
+		x, y, _ = transform(x, y, 0)
+		return x, y
+	}, nil
+}
+
+// sourceEPSGCode determines the EPSG code of shpPath's coordinates, either
+// from the explicit sSRS override or by parsing the WKT in the shapefile's
+// sibling .prj file.
+//
+// The .prj parsing only looks for an explicit AUTHORITY["EPSG","<code>"]
+// clause, which plenty of real-world .prj files (notably ArcGIS exports of
+// projected CRSes) don't carry at all. wgs84, the EPSG registry already in
+// use here, has no WKT parser to fall back on, and matching a PROJCS[...]
+// definition's parameters against its table well enough to be trustworthy
+// (rather than guessing a plausible-looking but wrong EPSG code) is more
+// than a regex can responsibly do. Short of that, this fails honestly via
+// the "no EPSG authority found" error below rather than silently picking
+// the wrong SRS; -s-srs is the workaround.
+func sourceEPSGCode(shpPath, sSRS string) (int, error) {
+	if sSRS != "" {
+		code, err := parseEPSGCode(sSRS)
+		if err != nil {
+			return 0, fmt.Errorf("-s-srs: %w", err)
+		}
+		return code, nil
+	}
+
+	prjPath := strings.TrimSuffix(shpPath, filepath.Ext(shpPath)) + ".prj"
+	wkt, err := os.ReadFile(prjPath)
+	if err != nil {
+		return 0, fmt.Errorf("%s: %w (pass -s-srs to override)", prjPath, err)
+	}
+
+	matches := epsgAuthorityRe.FindAllSubmatch(wkt, -1)
+	if len(matches) == 0 {
+		return 0, fmt.Errorf("%s: no EPSG authority found, pass -s-srs to override", prjPath)
+	}
+	// The last AUTHORITY clause in the WKT names the overall (outermost) CRS.
+	return strconv.Atoi(string(matches[len(matches)-1][1]))
+}
+
+func parseEPSGCode(srs string) (int, error) {
+	srs = strings.TrimPrefix(strings.ToUpper(strings.TrimSpace(srs)), "EPSG:")
+	code, err := strconv.Atoi(srs)
+	if err != nil {
+		return 0, fmt.Errorf("invalid SRS %q, expected EPSG:<code>", srs)
+	}
+	return code, nil
+}
+
+// warnIfOutOfRange logs a warning when a coordinate that has just been
+// reprojected falls outside the lon/lat bounds GeoJSON expects, which
+// usually means the source or target SRS was misidentified.
+func warnIfOutOfRange(x, y float64) {
+	if x < -180 || x > 180 || y < -90 || y > 90 {
+		log.Printf("warning: reprojected coordinate (%g, %g) is outside [-180,180]/[-90,90]", x, y)
+	}
+}
@@ -0,0 +1,409 @@
+package shp2geojson
+
+import (
+	"fmt"
+	"log"
+	"math"
+
+	"github.com/jonas-p/go-shp"
+	"github.com/paulmach/orb"
+	"github.com/paulmach/orb/planar"
+)
+
+// MultiPatch part types, per the shapefile spec. shp.MultiPatch.PartTypes
+// holds one of these per part; go-shp doesn't define named constants for
+// them.
+const (
+	partTypeTriangleStrip int32 = 0
+	partTypeTriangleFan   int32 = 1
+	partTypeOuterRing     int32 = 2
+	partTypeInnerRing     int32 = 3
+	partTypeFirstRing     int32 = 4
+	partTypeRing          int32 = 5
+)
+
+// ShapeToFeature converts a single shape and its attributes into a
+// Feature. proj, when non-nil, is applied to every coordinate. dropZ
+// discards a shape's Z values even when it is one of the Z-flavored shape
+// types (PointZ, PolyLineZ, PolygonZ, MultiPointZ, MultiPatch), producing
+// strict two-dimensional RFC 7946 output instead of the GeoJSON 2008
+// convention of 3-element [x,y,z] coordinates.
+func ShapeToFeature(shape shp.Shape, attrs []shp.Attribute, proj ProjectFunc, dropZ bool) *Feature {
+	var g interface{}
+	switch s := shape.(type) {
+	case *shp.Null:
+		g = nil
+	case *shp.Point:
+		g = convertPoint(s, proj)
+	case *shp.PointZ:
+		g = convertPointZ(s, proj, dropZ)
+	case *shp.PointM:
+		g = orb.Point(convertPointXY(s.X, s.Y, proj))
+	case *shp.PolyLine:
+		if s.NumParts == 1 {
+			g = convertLineString(s, proj)
+		} else if s.NumParts > 1 {
+			g = convertMultiLineString(s, proj)
+		}
+	case *shp.PolyLineZ:
+		g = convertPolyLineZ(s, proj, dropZ)
+	case *shp.PolyLineM:
+		g = convertPolyLineM(s, proj)
+	case *shp.Polygon:
+		g = groupRings(ringsFromPoints(s.Points, s.Parts, s.NumParts, s.NumPoints, proj))
+	case *shp.PolygonZ:
+		g = convertPolygonZ(s, proj, dropZ)
+	case *shp.PolygonM:
+		g = groupRings(ringsFromPoints(s.Points, s.Parts, s.NumParts, s.NumPoints, proj))
+	case *shp.MultiPoint:
+		g = convertMultiPoint(s, proj)
+	case *shp.MultiPointZ:
+		g = convertMultiPointZ(s, proj, dropZ)
+	case *shp.MultiPointM:
+		g = convertMultiPointM(s, proj)
+	case *shp.MultiPatch:
+		g = convertMultiPatch(s, proj, dropZ)
+	default:
+		panic(fmt.Sprintf("unsupported geometry type %v", s))
+	}
+
+	f := newFeature(g)
+	for _, attr := range attrs {
+		f.Properties[attr.Name()] = attr.Value()
+	}
+	return f
+}
+
+func convertPointXY(x, y float64, proj ProjectFunc) orb.Point {
+	if proj != nil {
+		x, y = proj(x, y)
+		warnIfOutOfRange(x, y)
+	}
+	return orb.Point{x, y}
+}
+
+func convertPoint(s *shp.Point, proj ProjectFunc) orb.Point {
+	return convertPointXY(s.X, s.Y, proj)
+}
+
+func convertPointZ(s *shp.PointZ, proj ProjectFunc, dropZ bool) interface{} {
+	p := convertPointXY(s.X, s.Y, proj)
+	if dropZ {
+		return p
+	}
+	return &geometry3{typ: "Point", coords: [3]float64{p[0], p[1], s.Z}, flat: p}
+}
+
+func convertLineString(s *shp.PolyLine, proj ProjectFunc) orb.LineString {
+	g := orb.LineString{}
+	for _, p := range s.Points {
+		g = append(g, convertPoint(&p, proj))
+	}
+	return g
+}
+
+func convertMultiPoint(s *shp.MultiPoint, proj ProjectFunc) orb.MultiPoint {
+	g := orb.MultiPoint{}
+	for _, p := range s.Points {
+		g = append(g, convertPoint(&p, proj))
+	}
+	return g
+}
+
+func convertMultiLineString(s *shp.PolyLine, proj ProjectFunc) orb.MultiLineString {
+	g := orb.MultiLineString{}
+	for _, r := range partRanges(s.Parts, s.NumParts, s.NumPoints) {
+		l := orb.LineString{}
+		for _, p := range s.Points[r[0]:r[1]] {
+			l = append(l, convertPoint(&p, proj))
+		}
+		g = append(g, l)
+	}
+	return g
+}
+
+// convertPolyLineZ converts a PolyLineZ, preserving each point's Z unless
+// dropZ is set.
+func convertPolyLineZ(s *shp.PolyLineZ, proj ProjectFunc, dropZ bool) interface{} {
+	ranges := partRanges(s.Parts, s.NumParts, s.NumPoints)
+	if dropZ {
+		if s.NumParts == 1 {
+			return lineFromRange(s.Points, ranges[0], proj)
+		}
+		g := orb.MultiLineString{}
+		for _, r := range ranges {
+			g = append(g, lineFromRange(s.Points, r, proj))
+		}
+		return g
+	}
+
+	if s.NumParts == 1 {
+		r := ranges[0]
+		flat := lineFromRange(s.Points, r, proj)
+		return &geometry3{typ: "LineString", coords: positions3(s.Points[r[0]:r[1]], s.ZArray[r[0]:r[1]], proj), flat: flat}
+	}
+	coords := make([][][3]float64, len(ranges))
+	flat := orb.MultiLineString{}
+	for i, r := range ranges {
+		flat = append(flat, lineFromRange(s.Points, r, proj))
+		coords[i] = positions3(s.Points[r[0]:r[1]], s.ZArray[r[0]:r[1]], proj)
+	}
+	return &geometry3{typ: "MultiLineString", coords: coords, flat: flat}
+}
+
+// convertPolyLineM converts a PolyLineM. GeoJSON has no measure dimension,
+// so M is dropped and only X/Y survive.
+func convertPolyLineM(s *shp.PolyLineM, proj ProjectFunc) interface{} {
+	ranges := partRanges(s.Parts, s.NumParts, s.NumPoints)
+	if s.NumParts == 1 {
+		return lineFromRange(s.Points, ranges[0], proj)
+	}
+	g := orb.MultiLineString{}
+	for _, r := range ranges {
+		g = append(g, lineFromRange(s.Points, r, proj))
+	}
+	return g
+}
+
+func lineFromRange(points []shp.Point, r [2]int32, proj ProjectFunc) orb.LineString {
+	l := orb.LineString{}
+	for _, p := range points[r[0]:r[1]] {
+		l = append(l, convertPoint(&p, proj))
+	}
+	return l
+}
+
+func convertMultiPointZ(s *shp.MultiPointZ, proj ProjectFunc, dropZ bool) interface{} {
+	flat := orb.MultiPoint{}
+	for _, p := range s.Points {
+		flat = append(flat, convertPoint(&p, proj))
+	}
+	if dropZ {
+		return flat
+	}
+	return &geometry3{typ: "MultiPoint", coords: positions3(s.Points, s.ZArray, proj), flat: flat}
+}
+
+func convertMultiPointM(s *shp.MultiPointM, proj ProjectFunc) orb.MultiPoint {
+	g := orb.MultiPoint{}
+	for _, p := range s.Points {
+		g = append(g, convertPoint(&p, proj))
+	}
+	return g
+}
+
+// convertPolygonZ converts a PolygonZ, preserving every ring vertex's Z
+// unless dropZ is set. Ring-to-polygon grouping mirrors convertMultiPolygon
+// exactly, so both share the groupRings/groupRingCoords helpers.
+func convertPolygonZ(s *shp.PolygonZ, proj ProjectFunc, dropZ bool) interface{} {
+	ranges := partRanges(s.Parts, s.NumParts, s.NumPoints)
+	rings := make([]orb.Ring, len(ranges))
+	for i, r := range ranges {
+		rings[i] = ringFromRange(s.Points, r, proj)
+	}
+	flat := groupRings(rings)
+	if dropZ {
+		return flat
+	}
+
+	ringCoords := make([][][3]float64, len(ranges))
+	for i, r := range ranges {
+		ringCoords[i] = positions3(s.Points[r[0]:r[1]], s.ZArray[r[0]:r[1]], proj)
+	}
+	return &geometry3{typ: "MultiPolygon", coords: groupRingCoords(rings, ringCoords), flat: flat}
+}
+
+// convertMultiPatch treats each ring-like part (OuterRing, InnerRing,
+// FirstRing or Ring) as a polygon ring, grouped the same way as
+// convertMultiPolygon. TriangleStrip/TriangleFan parts, used by 3D
+// building data to describe surfaces that aren't flat rings, can't be
+// turned into a polygon boundary this way; rather than fabricate a bogus
+// ring out of their vertices, those parts are skipped and logged, so the
+// output is incomplete rather than silently wrong.
+func convertMultiPatch(s *shp.MultiPatch, proj ProjectFunc, dropZ bool) interface{} {
+	ranges := partRanges(s.Parts, s.NumParts, s.NumPoints)
+
+	var rings []orb.Ring
+	var ringRanges [][2]int32
+	skipped := 0
+	for i, r := range ranges {
+		switch s.PartTypes[i] {
+		case partTypeOuterRing, partTypeInnerRing, partTypeFirstRing, partTypeRing:
+			rings = append(rings, ringFromRange(s.Points, r, proj))
+			ringRanges = append(ringRanges, r)
+		default:
+			skipped++
+		}
+	}
+	if skipped > 0 {
+		log.Printf("warning: MultiPatch shape has %d TriangleStrip/TriangleFan part(s), which are not reconstructed into polygon rings and have been skipped", skipped)
+	}
+
+	flat := groupRings(rings)
+	if dropZ {
+		return flat
+	}
+
+	ringCoords := make([][][3]float64, len(ringRanges))
+	for i, r := range ringRanges {
+		ringCoords[i] = positions3(s.Points[r[0]:r[1]], s.ZArray[r[0]:r[1]], proj)
+	}
+	return &geometry3{typ: "MultiPolygon", coords: groupRingCoords(rings, ringCoords), flat: flat}
+}
+
+func ringFromRange(points []shp.Point, r [2]int32, proj ProjectFunc) orb.Ring {
+	ring := orb.Ring{}
+	for _, p := range points[r[0]:r[1]] {
+		ring = append(ring, convertPoint(&p, proj))
+	}
+	return ring
+}
+
+func ringsFromPoints(points []shp.Point, parts []int32, numParts, numPoints int32, proj ProjectFunc) []orb.Ring {
+	ranges := partRanges(parts, numParts, numPoints)
+	rings := make([]orb.Ring, len(ranges))
+	for i, r := range ranges {
+		rings[i] = ringFromRange(points, r, proj)
+	}
+	return rings
+}
+
+// partRanges expands a shapefile's flat Parts index into [start,end) point
+// ranges, one per part.
+func partRanges(parts []int32, numParts, numPoints int32) [][2]int32 {
+	ranges := make([][2]int32, numParts)
+	for i, start := range parts {
+		end := numPoints
+		if int32(i) < numParts-1 {
+			end = parts[i+1]
+		}
+		ranges[i] = [2]int32{start, end}
+	}
+	return ranges
+}
+
+// positions3 pairs up points with their Z values into GeoJSON 3D
+// positions, applying proj to X/Y.
+func positions3(points []shp.Point, zs []float64, proj ProjectFunc) [][3]float64 {
+	out := make([][3]float64, len(points))
+	for i, p := range points {
+		x, y := p.X, p.Y
+		if proj != nil {
+			x, y = proj(x, y)
+			warnIfOutOfRange(x, y)
+		}
+		out[i] = [3]float64{x, y, zs[i]}
+	}
+	return out
+}
+
+// ringGroup is one output polygon: an outer ring followed by the holes
+// assigned to it, each referenced by index into the rings slice passed to
+// assignRings.
+type ringGroup struct {
+	outer int
+	holes []int
+}
+
+// assignRings classifies rings as outer or hole by the shapefile/ESRI
+// winding convention (clockwise outer, counterclockwise hole) and assigns
+// each hole to the smallest-area outer ring that contains one of its
+// vertices, rather than relying on part order. A hole contained by no
+// outer ring is malformed input (or floating-point edge cases in the
+// point-in-ring test), but rather than silently dropping it, it becomes
+// its own outer ring, per shapefile's convention that an unmatched ring is
+// its own polygon. Rings with fewer than 3 points are ignored.
+func assignRings(rings []orb.Ring) []ringGroup {
+	type outerInfo struct {
+		idx  int
+		area float64
+	}
+	var outers []outerInfo
+	var holes []int
+	for i, r := range rings {
+		if len(r) < 3 {
+			continue
+		}
+		if r.Orientation() == orb.CW {
+			outers = append(outers, outerInfo{idx: i, area: math.Abs(planar.Area(r))})
+		} else {
+			holes = append(holes, i)
+		}
+	}
+
+	groups := make([]ringGroup, len(outers))
+	for i, o := range outers {
+		groups[i] = ringGroup{outer: o.idx}
+	}
+
+	for _, h := range holes {
+		best := -1
+		bestArea := math.Inf(1)
+		for i, o := range outers {
+			if planar.RingContains(rings[o.idx], rings[h][0]) && o.area < bestArea {
+				best = i
+				bestArea = o.area
+			}
+		}
+		if best >= 0 {
+			groups[best].holes = append(groups[best].holes, h)
+		} else {
+			groups = append(groups, ringGroup{outer: h})
+		}
+	}
+	return groups
+}
+
+// groupRings assembles rings into polygons using assignRings, emitting
+// RFC 7946-correct winding (exterior rings counterclockwise, holes
+// clockwise). Winding is forced by each ring's role in its group rather
+// than assumed from assignRings' ESRI-convention classification, since an
+// orphaned hole promoted to its own outer (see assignRings) keeps its
+// original CCW winding, which is already RFC 7946-correct for an outer.
+func groupRings(rings []orb.Ring) orb.MultiPolygon {
+	g := make(orb.MultiPolygon, 0, len(rings))
+	for _, group := range assignRings(rings) {
+		poly := make(orb.Polygon, 0, 1+len(group.holes))
+		poly = append(poly, orientRing(rings[group.outer], orb.CCW))
+		for _, h := range group.holes {
+			poly = append(poly, orientRing(rings[h], orb.CW))
+		}
+		g = append(g, poly)
+	}
+	return g
+}
+
+// groupRingCoords mirrors groupRings' grouping and winding decisions over
+// the matching slice of 3D ring coordinates, so the Z-aware geometry
+// groups its rings into polygons exactly the way the 2D one does.
+func groupRingCoords(rings []orb.Ring, ringCoords [][][3]float64) [][][][3]float64 {
+	g := make([][][][3]float64, 0, len(rings))
+	for _, group := range assignRings(rings) {
+		poly := make([][][3]float64, 0, 1+len(group.holes))
+		poly = append(poly, orientRingCoords(rings[group.outer], ringCoords[group.outer], orb.CCW))
+		for _, h := range group.holes {
+			poly = append(poly, orientRingCoords(rings[h], ringCoords[h], orb.CW))
+		}
+		g = append(g, poly)
+	}
+	return g
+}
+
+// orientRingCoords returns coords, reversed if necessary, so the winding
+// of its paired 2D ring matches want -- the 3D counterpart of orientRing,
+// reversing in lockstep with it.
+func orientRingCoords(ring orb.Ring, coords [][3]float64, want orb.Orientation) [][3]float64 {
+	if ring.Orientation() == want {
+		return coords
+	}
+	return reverse3(coords)
+}
+
+// reverse3 returns a reversed copy of a ring's 3D positions.
+func reverse3(coords [][3]float64) [][3]float64 {
+	out := make([][3]float64, len(coords))
+	for i, c := range coords {
+		out[len(coords)-1-i] = c
+	}
+	return out
+}
@@ -0,0 +1,123 @@
+package shp2geojson
+
+import (
+	"testing"
+
+	"github.com/paulmach/orb"
+)
+
+// square returns a closed CW ring for the box spanned by (x0,y0)-(x1,y1) --
+// the ESRI/shapefile convention for an outer ring.
+func square(x0, y0, x1, y1 float64) orb.Ring {
+	r := orb.Ring{
+		{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}, {x0, y0},
+	}
+	if r.Orientation() != orb.CW {
+		r.Reverse()
+	}
+	return r
+}
+
+// squareHole returns a closed CCW ring for the same box -- the
+// ESRI/shapefile convention for a hole.
+func squareHole(x0, y0, x1, y1 float64) orb.Ring {
+	r := orb.Ring{
+		{x0, y0}, {x1, y0}, {x1, y1}, {x0, y1}, {x0, y0},
+	}
+	if r.Orientation() != orb.CCW {
+		r.Reverse()
+	}
+	return r
+}
+
+func TestGroupRings_DonutWithIsland(t *testing.T) {
+	outer := square(0, 0, 5, 5)
+	hole := squareHole(1, 1, 4, 4)
+	island := square(2, 2, 3, 3)
+
+	if outer.Orientation() != orb.CW {
+		t.Fatalf("test fixture bug: outer ring isn't CW")
+	}
+	if hole.Orientation() != orb.CCW {
+		t.Fatalf("test fixture bug: hole ring isn't CCW")
+	}
+
+	got := groupRings([]orb.Ring{outer, hole, island})
+	if len(got) != 2 {
+		t.Fatalf("got %d polygons, want 2 (donut + island)", len(got))
+	}
+
+	donut, isle := got[0], got[1]
+	if len(donut) != 2 {
+		t.Fatalf("donut polygon has %d rings, want 2 (outer + hole)", len(donut))
+	}
+	if len(isle) != 1 {
+		t.Fatalf("island polygon has %d rings, want 1", len(isle))
+	}
+
+	if donut[0].Orientation() != orb.CCW {
+		t.Errorf("donut outer ring orientation = %v, want CCW", donut[0].Orientation())
+	}
+	if donut[1].Orientation() != orb.CW {
+		t.Errorf("donut hole ring orientation = %v, want CW", donut[1].Orientation())
+	}
+	if isle[0].Orientation() != orb.CCW {
+		t.Errorf("island outer ring orientation = %v, want CCW", isle[0].Orientation())
+	}
+}
+
+func TestGroupRings_InterleavedParts(t *testing.T) {
+	outerA := square(0, 0, 5, 5)
+	outerB := square(10, 0, 15, 5)
+	holeA := squareHole(1, 1, 4, 4)
+	holeB := squareHole(11, 1, 14, 4)
+
+	// Parts ordered outerA, outerB, holeA, holeB: the two outer rings
+	// appear back to back, so a heuristic that starts a new polygon on
+	// every CW ring and otherwise appends would wrongly attach holeA to
+	// outerB.
+	got := groupRings([]orb.Ring{outerA, outerB, holeA, holeB})
+	if len(got) != 2 {
+		t.Fatalf("got %d polygons, want 2", len(got))
+	}
+
+	for _, poly := range got {
+		if len(poly) != 2 {
+			t.Fatalf("polygon has %d rings, want 2 (outer + its own hole)", len(poly))
+		}
+	}
+
+	bound := func(r orb.Ring) orb.Bound { return r.Bound() }
+	firstIsA := bound(got[0][0]).Min[0] < 5
+	var aPoly, bPoly orb.Polygon
+	if firstIsA {
+		aPoly, bPoly = got[0], got[1]
+	} else {
+		aPoly, bPoly = got[1], got[0]
+	}
+
+	if !aPoly[1].Bound().Min.Equal(orb.Point{1, 1}) {
+		t.Errorf("polygon A's hole = %v, want the (1,1)-(4,4) box", aPoly[1])
+	}
+	if !bPoly[1].Bound().Min.Equal(orb.Point{11, 1}) {
+		t.Errorf("polygon B's hole = %v, want the (11,1)-(14,4) box", bPoly[1])
+	}
+}
+
+func TestGroupRings_OrphanHole(t *testing.T) {
+	orphan := squareHole(0, 0, 5, 5)
+
+	got := groupRings([]orb.Ring{orphan})
+	if len(got) != 1 {
+		t.Fatalf("got %d polygons, want 1 (the orphan becomes its own outer)", len(got))
+	}
+	if len(got[0]) != 1 {
+		t.Fatalf("orphan polygon has %d rings, want 1", len(got[0]))
+	}
+	if got[0][0].Orientation() != orb.CCW {
+		t.Errorf("orphan outer ring orientation = %v, want CCW", got[0][0].Orientation())
+	}
+	if !got[0][0].Bound().Equal(orphan.Bound()) {
+		t.Errorf("orphan outer ring bound = %v, want %v", got[0][0].Bound(), orphan.Bound())
+	}
+}
@@ -0,0 +1,36 @@
+package shp2geojson
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/paulmach/orb/encoding/wkb"
+)
+
+// wkbWriter writes each feature's geometry as a WKB blob, length-prefixed
+// with a little-endian uint32 so a reader can split the stream back into
+// records. WKB has no room for attributes, so feature properties are
+// dropped; use -format pgcopy or -format gpkg to keep them.
+type wkbWriter struct {
+	w io.Writer
+}
+
+func newWKBWriter(w io.Writer) *wkbWriter {
+	return &wkbWriter{w: w}
+}
+
+func (ww *wkbWriter) WriteFeature(f *Feature) error {
+	b, err := wkb.Marshal(to2D(f.Geometry))
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(ww.w, binary.LittleEndian, uint32(len(b))); err != nil {
+		return err
+	}
+	_, err = ww.w.Write(b)
+	return err
+}
+
+func (ww *wkbWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,100 @@
+package shp2geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// FeatureWriter writes shapefile features out in some target format.
+// Callers must call Close when done so any footer/trailer bytes are
+// flushed.
+type FeatureWriter interface {
+	WriteFeature(f *Feature) error
+	Close() error
+}
+
+// NewFeatureWriter returns the FeatureWriter for format, one of "geojson"
+// (the default), "wkb", "gpkg" or "pgcopy". fields describes the DBF
+// attribute schema and is used by the gpkg and pgcopy writers to declare
+// SQL columns; it is ignored by geojson and wkb.
+func NewFeatureWriter(format string, w io.Writer, fields []AttributeField, opts Options) (FeatureWriter, error) {
+	switch format {
+	case "", "geojson":
+		return newGeoJSONWriter(w, opts), nil
+	case "wkb":
+		return newWKBWriter(w), nil
+	case "pgcopy":
+		return newPgCopyWriter(w, fields), nil
+	case "gpkg":
+		return newGpkgWriter(w, fields)
+	default:
+		return nil, fmt.Errorf("unsupported -format %q", format)
+	}
+}
+
+// AttributeField describes one DBF attribute column, independent of the
+// go-shp Field representation so writers don't need to import go-shp just
+// to read a name and a type.
+type AttributeField struct {
+	Name string
+	Type AttributeType
+}
+
+// AttributeType is a DBF field type, used to pick an appropriate SQL/
+// GeoPackage column type instead of flattening every attribute to JSON.
+type AttributeType byte
+
+const (
+	AttributeText   AttributeType = 'C'
+	AttributeDate   AttributeType = 'D'
+	AttributeFloat  AttributeType = 'F'
+	AttributeNumber AttributeType = 'N'
+)
+
+// geoJSONWriter streams a FeatureCollection (or newline-delimited
+// Features) without buffering them in memory.
+type geoJSONWriter struct {
+	w       io.Writer
+	encoder *json.Encoder
+	ndjson  bool
+	first   bool
+}
+
+func newGeoJSONWriter(w io.Writer, opts Options) *geoJSONWriter {
+	encoder := json.NewEncoder(w)
+	if opts.Pretty && !opts.NDJSON {
+		encoder.SetIndent("", "  ")
+	}
+	return &geoJSONWriter{w: w, encoder: encoder, ndjson: opts.NDJSON, first: true}
+}
+
+func (g *geoJSONWriter) WriteFeature(f *Feature) error {
+	if !g.ndjson {
+		if g.first {
+			if _, err := io.WriteString(g.w, `{"type":"FeatureCollection","features":[`); err != nil {
+				return err
+			}
+		} else {
+			if _, err := io.WriteString(g.w, ","); err != nil {
+				return err
+			}
+		}
+	}
+	g.first = false
+	return g.encoder.Encode(f)
+}
+
+func (g *geoJSONWriter) Close() error {
+	if g.ndjson {
+		return nil
+	}
+	if g.first {
+		// no features were written, still emit a valid empty collection
+		if _, err := io.WriteString(g.w, `{"type":"FeatureCollection","features":[`); err != nil {
+			return err
+		}
+	}
+	_, err := io.WriteString(g.w, "]}")
+	return err
+}